@@ -0,0 +1,101 @@
+/*
+ * filter.go - Ready-made FilterFuncs for use with ForEachMount and
+ * FilterFilesystems.
+ *
+ * Copyright 2017 Google Inc.
+ * Author: Joe Richey (joerichey@google.com)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package filesystem
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// pseudoFilesystemTypes are virtual filesystems that never back an fscrypt
+// policy and are not worth the cost of canonicalizing or stat'ing.
+var pseudoFilesystemTypes = map[string]bool{
+	"proc":        true,
+	"sysfs":       true,
+	"cgroup":      true,
+	"cgroup2":     true,
+	"tmpfs":       true,
+	"devtmpfs":    true,
+	"devpts":      true,
+	"overlay":     true,
+	"mqueue":      true,
+	"debugfs":     true,
+	"tracefs":     true,
+	"securityfs":  true,
+	"pstore":      true,
+	"bpf":         true,
+	"configfs":    true,
+	"fusectl":     true,
+	"binfmt_misc": true,
+	"autofs":      true,
+	"rpc_pipefs":  true,
+}
+
+// PrefixFilter returns a FilterFunc that skips every Mount whose path is not
+// path itself or a descendant of path.
+func PrefixFilter(path string) FilterFunc {
+	path = filepath.Clean(path)
+	return func(mnt *Mount) (skip, stopAfter bool) {
+		if path == "/" {
+			// Every Mount path already starts with "/", so the
+			// general case below (which checks for path+"/") would
+			// incorrectly skip everything except "/" itself.
+			return false, false
+		}
+		return mnt.Path != path && !strings.HasPrefix(mnt.Path, path+"/"), false
+	}
+}
+
+// FSTypeFilter returns a FilterFunc that skips every Mount whose
+// FilesystemType is not one of types.
+func FSTypeFilter(types ...string) FilterFunc {
+	wanted := make(map[string]bool, len(types))
+	for _, fsType := range types {
+		wanted[fsType] = true
+	}
+	return func(mnt *Mount) (skip, stopAfter bool) {
+		return !wanted[mnt.FilesystemType], false
+	}
+}
+
+// DeviceFilter returns a FilterFunc that skips every Mount whose Device is
+// not dev, and stops the scan as soon as a matching Mount is found. On Linux,
+// a FilterFunc runs before Mount.Device is canonicalized, so dev should already
+// be in the form that appears in mountinfo's mount source field (as opposed to,
+// say, a /dev/disk/by-uuid symlink); callers that only have a canonicalized
+// path should resolve dev to its mountinfo form first. On FreeBSD and OpenBSD
+// the filter sees the canonicalized Device instead, which is "" for a mount
+// source that is not a device.
+func DeviceFilter(dev string) FilterFunc {
+	return func(mnt *Mount) (skip, stopAfter bool) {
+		match := mnt.Device == dev
+		return !match, match
+	}
+}
+
+// PseudoFilesystemFilter returns a FilterFunc that skips virtual filesystems
+// (proc, sysfs, cgroup, tmpfs, overlay, and similar) that can never back an
+// fscrypt policy.
+func PseudoFilesystemFilter() FilterFunc {
+	return func(mnt *Mount) (skip, stopAfter bool) {
+		return pseudoFilesystemTypes[mnt.FilesystemType], false
+	}
+}
@@ -1,7 +1,9 @@
 /*
- * mountpoint.go - Contains all the functionality for finding mountpoints and
- * using UUIDs to refer to them. Specifically, we can find the mountpoint of a
- * path, get info about a mountpoint, and find mountpoints with a specific UUID.
+ * mountpoint.go - Contains the platform-independent functionality for finding
+ * mountpoints and using UUIDs to refer to them. Specifically, we can find the
+ * mountpoint of a path, get info about a mountpoint, and find mountpoints with
+ * a specific UUID. The platform-specific mount scanning lives in
+ * mountpoint_linux.go, mountpoint_bsd.go, and mountpoint_unsupported.go.
  *
  * Copyright 2017 Google Inc.
  * Author: Joe Richey (joerichey@google.com)
@@ -22,7 +24,6 @@
 package filesystem
 
 import (
-	"bufio"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -37,23 +38,27 @@ import (
 )
 
 var (
-	// These maps hold data about the state of the system's mountpoints.
-	mountsByPath   map[string]*Mount
-	mountsByDevice map[string][]*Mount
-	// Used to make the mount functions thread safe
-	mountMutex sync.Mutex
-	// True if the maps have been successfully initialized.
-	mountsInitialized bool
-	// Supported tokens for filesystem links
-	uuidToken = "UUID"
-	// Location to perform UUID lookup
-	uuidDirectory = "/dev/disk/by-uuid"
+	// mountCaches holds a *mountCache per mount source that has been
+	// scanned so far, keyed by mount source (selfMountSource or
+	// pidMountSource(pid)). mountMutex guards it and makes the mount
+	// functions thread safe.
+	mountCaches = make(map[string]*mountCache)
+	mountMutex  sync.Mutex
+	// Supported tokens for filesystem links. The directories used to
+	// resolve each one to a device are platform-specific; see
+	// tokenDirectories in mountpoint_linux.go / mountpoint_bsd.go.
+	uuidToken      = "UUID"
+	labelToken     = "LABEL"
+	partuuidToken  = "PARTUUID"
+	partlabelToken = "PARTLABEL"
+	// Preference order used by makeLink when the caller doesn't request a
+	// specific token type.
+	defaultTokenPreference = []string{uuidToken, partuuidToken, labelToken}
 )
 
 // Unescape octal-encoded escape sequences in a string from the mountinfo file.
-// The kernel encodes the ' ', '\t', '\n', and '\\' bytes this way.  This
-// function exactly inverts what the kernel does, including by preserving
-// invalid UTF-8.
+// The kernel encodes the ' ', '\t', '\n', and '\\' bytes this way. This function exactly inverts what the
+// kernel does, including by preserving invalid UTF-8.
 func unescapeString(str string) string {
 	var sb strings.Builder
 	for i := 0; i < len(str); i++ {
@@ -69,98 +74,175 @@ func unescapeString(str string) string {
 	return sb.String()
 }
 
-// Parse one line of /proc/self/mountinfo.
-//
-// The line contains the following space-separated fields:
-//	[0] mount ID
-//	[1] parent ID
-//	[2] major:minor
-//	[3] root
-//	[4] mount point
-//	[5] mount options
-//	[6...n-1] optional field(s)
-//	[n] separator
-//	[n+1] filesystem type
-//	[n+2] mount source
-//	[n+3] super options
-//
-// For more details, see https://www.kernel.org/doc/Documentation/filesystems/proc.txt
-func parseMountInfoLine(line string) *Mount {
-	fields := strings.Split(line, " ")
-	if len(fields) < 10 {
-		return nil
+// Propagation describes how mount and unmount events on a Mount propagate to
+// and from its peer mounts, as documented in
+// https://www.kernel.org/doc/Documentation/filesystems/sharedsubtree.txt.
+type Propagation int
+
+const (
+	// PropagationPrivate is the default: mount/unmount events on this
+	// Mount never propagate to or from any other mount.
+	PropagationPrivate Propagation = iota
+	// PropagationShared means mount/unmount events on this Mount
+	// propagate to and from its peer group (Mount.PeerGroup).
+	PropagationShared
+	// PropagationSlave means mount/unmount events propagate into this
+	// Mount from its master peer group (Mount.MasterID), but not back out.
+	PropagationSlave
+	// PropagationUnbindable is like PropagationPrivate, but the Mount
+	// additionally cannot be bind-mounted.
+	PropagationUnbindable
+)
+
+func (p Propagation) String() string {
+	switch p {
+	case PropagationShared:
+		return "shared"
+	case PropagationSlave:
+		return "slave"
+	case PropagationUnbindable:
+		return "unbindable"
+	default:
+		return "private"
+	}
+}
+
+// IsMountpoint reports whether path is itself the root of a mounted
+// filesystem, as opposed to an ordinary directory inside one. Where
+// supported, this uses a fast path that avoids scanning the whole mount
+// table; otherwise it falls back to FindMount.
+func IsMountpoint(path string) (bool, error) {
+	if mounted, handled, err := isMountpointFast(path); handled {
+		return mounted, err
+	}
+	return isMountpointSlow(path)
+}
+
+// isMountpointSlow determines whether path is a mountpoint using FindMount,
+// which requires scanning (or reusing the cached) mount table.
+func isMountpointSlow(path string) (bool, error) {
+	path, err := canonicalizePath(path)
+	if err != nil {
+		return false, err
+	}
+	mnt, err := FindMount(path)
+	if err != nil {
+		return false, err
 	}
+	return mnt.Path == path, nil
+}
+
+// labelSafeChars are the punctuation characters that blkid_encode_string (and
+// so udev) passes through unescaped, in addition to ASCII letters and digits.
+const labelSafeChars = "#+-.:=@_"
 
-	// Count the optional fields.  In case new fields are appended later,
-	// don't simply assume that n == len(fields) - 4.
-	n := 6
-	for fields[n] != "-" {
-		n++
-		if n >= len(fields) {
-			return nil
+// escapeLabel encodes a LABEL or PARTLABEL value the same way udev's
+// blkid_encode_string encodes it when generating /dev/disk/by-label (and
+// by-partlabel) symlink names: bytes that aren't alphanumeric or in
+// labelSafeChars become a lowercase "\xHH" hex escape, e.g. a space becomes
+// "\x20".
+func escapeLabel(label string) string {
+	var sb strings.Builder
+	for i := 0; i < len(label); i++ {
+		b := label[i]
+		switch {
+		case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9':
+			sb.WriteByte(b)
+		case strings.IndexByte(labelSafeChars, b) >= 0:
+			sb.WriteByte(b)
+		default:
+			fmt.Fprintf(&sb, `\x%02x`, b)
 		}
 	}
-	if n+3 >= len(fields) {
-		return nil
+	return sb.String()
+}
+
+// unescapeLabel reverses escapeLabel, decoding udev's "\xHH" hex escapes back
+// to the original bytes.
+func unescapeLabel(str string) string {
+	var sb strings.Builder
+	for i := 0; i < len(str); i++ {
+		if str[i] == '\\' && i+3 < len(str) && str[i+1] == 'x' {
+			if parsed, err := strconv.ParseUint(str[i+2:i+4], 16, 8); err == nil {
+				sb.WriteByte(byte(parsed))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(str[i])
 	}
+	return sb.String()
+}
 
-	var mnt *Mount = &Mount{}
-	mnt.Path = unescapeString(fields[4])
-	mnt.FilesystemType = unescapeString(fields[n+1])
-	mnt.Device = unescapeString(fields[n+2])
-	return mnt
+// mountCache holds the Mount mappings discovered from a single mount source,
+// e.g. the caller's own mount namespace or another process's.
+type mountCache struct {
+	byPath   map[string]*Mount
+	byDevice map[string][]*Mount
 }
 
-// loadMountInfo populates the Mount mappings by parsing /proc/self/mountinfo.
-// It returns an error if the Mount mappings cannot be populated.
-func loadMountInfo() error {
-	if mountsInitialized {
-		return nil
+// selfMountSource identifies the caller's own mount namespace. It's the
+// source used by AllFilesystems, FindMount, GetMount, and UpdateMountInfo.
+const selfMountSource = ""
+
+// pidMountSource identifies the mount namespace of the process with the given
+// pid.
+func pidMountSource(pid int) string {
+	return fmt.Sprintf("pid:%d", pid)
+}
+
+// newMountCache creates an empty mountCache ready to be filled in by a
+// platform-specific scanner.
+func newMountCache() *mountCache {
+	return &mountCache{
+		byPath:   make(map[string]*Mount),
+		byDevice: make(map[string][]*Mount),
+	}
+}
+
+// addMount records mnt in cache, as the platform-specific scanners do for
+// every Mount they discover.
+func (cache *mountCache) addMount(mnt *Mount) {
+	// Note this overrides the info if we have seen the mountpoint earlier
+	// in the scan. This is correct behavior because filesystems are
+	// discovered in mount order.
+	cache.byPath[mnt.Path] = mnt
+	if mnt.Device != "" {
+		cache.byDevice[mnt.Device] = append(cache.byDevice[mnt.Device], mnt)
 	}
-	mountsByPath = make(map[string]*Mount)
-	mountsByDevice = make(map[string][]*Mount)
+}
 
-	file, err := os.Open("/proc/self/mountinfo")
+// loadMountCache returns the mountCache for source, populating it with
+// scanMountsForSource if source hasn't been loaded (or was invalidated) since
+// the last call. The caller must hold mountMutex.
+func loadMountCache(source string) (*mountCache, error) {
+	if cache, ok := mountCaches[source]; ok {
+		return cache, nil
+	}
+	cache, err := scanMountsForSource(source)
 	if err != nil {
-		return err
-	}
-	defer file.Close()
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		mnt := parseMountInfoLine(line)
-		if mnt == nil {
-			log.Printf("ignoring invalid mountinfo line %q", line)
-			continue
-		}
+		return nil, err
+	}
+	mountCaches[source] = cache
+	return cache, nil
+}
 
-		// Skip invalid mountpoints
-		var err error
-		if mnt.Path, err = canonicalizePath(mnt.Path); err != nil {
-			log.Printf("getting mnt_dir: %v", err)
-			continue
-		}
-		// We can only use mountpoints that are directories for fscrypt.
-		if !isDir(mnt.Path) {
-			log.Printf("ignoring mountpoint %q because it is not a directory", mnt.Path)
-			continue
-		}
+// loadMountInfo populates the Mount mappings for the caller's own mount
+// namespace. It returns an error if the Mount mappings cannot be populated.
+// The caller must hold mountMutex.
+func loadMountInfo() error {
+	_, err := loadMountCache(selfMountSource)
+	return err
+}
 
-		// Note this overrides the info if we have seen the mountpoint
-		// earlier in the file. This is correct behavior because the
-		// filesystems are listed in mount order.
-		mountsByPath[mnt.Path] = mnt
-
-		mnt.Device, err = canonicalizePath(mnt.Device)
-		// Only use real valid devices (unlike cgroups, tmpfs, ...)
-		if err == nil && isDevice(mnt.Device) {
-			mountsByDevice[mnt.Device] = append(mountsByDevice[mnt.Device], mnt)
-		} else {
-			mnt.Device = ""
-		}
+// sortedMounts returns the Mounts in cache ordered by path.
+func sortedMounts(cache *mountCache) []*Mount {
+	mounts := make([]*Mount, 0, len(cache.byPath))
+	for _, mount := range cache.byPath {
+		mounts = append(mounts, mount)
 	}
-	mountsInitialized = true
-	return nil
+	sort.Sort(PathSorter(mounts))
+	return mounts
 }
 
 // AllFilesystems lists all the Mounts on the current system ordered by path.
@@ -168,17 +250,28 @@ func loadMountInfo() error {
 func AllFilesystems() ([]*Mount, error) {
 	mountMutex.Lock()
 	defer mountMutex.Unlock()
-	if err := loadMountInfo(); err != nil {
+	cache, err := loadMountCache(selfMountSource)
+	if err != nil {
 		return nil, err
 	}
+	return sortedMounts(cache), nil
+}
 
-	mounts := make([]*Mount, 0, len(mountsByPath))
-	for _, mount := range mountsByPath {
-		mounts = append(mounts, mount)
+// AllFilesystemsForPID is like AllFilesystems, but lists the Mounts visible in
+// the mount namespace of the process with the given pid instead of the
+// caller's own mount namespace. This lets a privileged helper driven from a
+// sidecar or container runtime inspect another process's mounts without
+// joining its mount namespace. The result is cached separately per pid, so it
+// does not affect, and is not clobbered by, the cache used by AllFilesystems
+// and FindMount. Use UpdateMountInfoForPID to see changes in that namespace.
+func AllFilesystemsForPID(pid int) ([]*Mount, error) {
+	mountMutex.Lock()
+	defer mountMutex.Unlock()
+	cache, err := loadMountCache(pidMountSource(pid))
+	if err != nil {
+		return nil, err
 	}
-
-	sort.Sort(PathSorter(mounts))
-	return mounts, nil
+	return sortedMounts(cache), nil
 }
 
 // UpdateMountInfo updates the filesystem mountpoint maps with the current state
@@ -186,10 +279,56 @@ func AllFilesystems() ([]*Mount, error) {
 func UpdateMountInfo() error {
 	mountMutex.Lock()
 	defer mountMutex.Unlock()
-	mountsInitialized = false
+	delete(mountCaches, selfMountSource)
 	return loadMountInfo()
 }
 
+// UpdateMountInfoForPID is like UpdateMountInfo, but updates the cache used by
+// AllFilesystemsForPID and FindMountForPID for the given pid's mount
+// namespace.
+func UpdateMountInfoForPID(pid int) error {
+	mountMutex.Lock()
+	defer mountMutex.Unlock()
+	source := pidMountSource(pid)
+	delete(mountCaches, source)
+	_, err := loadMountCache(source)
+	return err
+}
+
+// FilterFunc is called once for each Mount found while scanning for
+// mountpoints. It returns whether mnt should be skipped from the results, and
+// whether the scan should stop once mnt has been processed (e.g. because the
+// caller only needed the first match). On Linux, a FilterFunc runs before
+// mnt's path and device are canonicalized, so it can cheaply reject most of
+// the Mounts on a system (cgroups, tmpfs, ...) without paying for those
+// lookups. On FreeBSD and OpenBSD it runs after them, and only for Mounts
+// whose path is a directory, so it sees the canonicalized path and device.
+type FilterFunc func(mnt *Mount) (skip, stopAfter bool)
+
+// ForEachMount scans the current mount table, calling fn once for every Mount
+// that filter does not skip, in mount order. A nil filter matches every
+// Mount. If fn returns an error, the scan stops immediately and that error is
+// returned. Unlike AllFilesystems and FindMount, ForEachMount always rescans
+// and does not use or populate the cached mount maps.
+func ForEachMount(filter FilterFunc, fn func(mnt *Mount) error) error {
+	return forEachMountForSource(selfMountSource, filter, fn)
+}
+
+// FilterFilesystems lists the Mounts on the current system that filter does
+// not skip, ordered by path. See FilterFunc for how filter is applied.
+func FilterFilesystems(filter FilterFunc) ([]*Mount, error) {
+	var mounts []*Mount
+	if err := ForEachMount(filter, func(mnt *Mount) error {
+		mounts = append(mounts, mnt)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Sort(PathSorter(mounts))
+	return mounts, nil
+}
+
 // FindMount returns the corresponding Mount object for some path in a
 // filesystem. Note that in the case of a bind mounts there may be two Mount
 // objects for the same underlying filesystem. An error is returned if the path
@@ -197,20 +336,39 @@ func UpdateMountInfo() error {
 // been updated since the last call to one of the mount functions, run
 // UpdateMountInfo to see changes.
 func FindMount(path string) (*Mount, error) {
-	path, err := canonicalizePath(path)
+	mountMutex.Lock()
+	defer mountMutex.Unlock()
+	cache, err := loadMountCache(selfMountSource)
 	if err != nil {
 		return nil, err
 	}
+	return findMountInCache(cache, path)
+}
 
+// FindMountForPID is like FindMount, but looks up path in the mount namespace
+// of the process with the given pid, using the same per-pid cache as
+// AllFilesystemsForPID.
+func FindMountForPID(path string, pid int) (*Mount, error) {
 	mountMutex.Lock()
 	defer mountMutex.Unlock()
-	if err = loadMountInfo(); err != nil {
+	cache, err := loadMountCache(pidMountSource(pid))
+	if err != nil {
+		return nil, err
+	}
+	return findMountInCache(cache, path)
+}
+
+// findMountInCache implements FindMount's path-to-mountpoint resolution
+// against an already-loaded cache. The caller must hold mountMutex.
+func findMountInCache(cache *mountCache, path string) (*Mount, error) {
+	path, err := canonicalizePath(path)
+	if err != nil {
 		return nil, err
 	}
 
 	// Traverse up the directory tree until we find a mountpoint
 	for {
-		if mnt, ok := mountsByPath[path]; ok {
+		if mnt, ok := cache.byPath[path]; ok {
 			return mnt, nil
 		}
 
@@ -235,11 +393,12 @@ func GetMount(mountpoint string) (*Mount, error) {
 
 	mountMutex.Lock()
 	defer mountMutex.Unlock()
-	if err = loadMountInfo(); err != nil {
+	cache, err := loadMountCache(selfMountSource)
+	if err != nil {
 		return nil, err
 	}
 
-	if mnt, ok := mountsByPath[mountpoint]; ok {
+	if mnt, ok := cache.byPath[mountpoint]; ok {
 		return mnt, nil
 	}
 
@@ -248,74 +407,136 @@ func GetMount(mountpoint string) (*Mount, error) {
 
 // getMountsFromLink returns the Mount objects which match the provided link.
 // This link is formatted as a tag (e.g. <token>=<value>) similar to how they
-// appear in "/etc/fstab". Currently, only "UUID" tokens are supported. Note
-// that this can match multiple Mounts (due to the existence of bind mounts). An
-// error is returned if the link is invalid or we cannot load the required mount
-// data. If a filesystem has been updated since the last call to one of the
-// mount functions, run UpdateMountInfo to see the change.
+// appear in "/etc/fstab". The "UUID", "LABEL", "PARTUUID", and "PARTLABEL"
+// tokens are supported, though which directories back them is platform
+// specific. Note that this can match multiple Mounts (due to the existence of
+// bind mounts). An error is returned if the link is invalid or we cannot load
+// the required mount data. If a filesystem has been updated since the last
+// call to one of the mount functions, run UpdateMountInfo to see the change.
 func getMountsFromLink(link string) ([]*Mount, error) {
 	// Parse the link
-	linkComponents := strings.Split(link, "=")
+	linkComponents := strings.SplitN(link, "=", 2)
 	if len(linkComponents) != 2 {
 		return nil, errors.Wrapf(ErrFollowLink, "link %q format is invalid", link)
 	}
 	token := linkComponents[0]
 	value := linkComponents[1]
-	if token != uuidToken {
+	directories, ok := tokenDirectories[token]
+	if !ok {
 		return nil, errors.Wrapf(ErrFollowLink, "token type %q not supported", token)
 	}
 
-	// See if UUID points to an existing device
-	searchPath := filepath.Join(uuidDirectory, value)
-	if filepath.Base(searchPath) != value {
-		return nil, errors.Wrapf(ErrFollowLink, "value %q is not a UUID", value)
+	// See if the tag points to an existing device. LABEL and PARTLABEL
+	// values may contain characters (like spaces) that udev escapes in
+	// the symlink name. Some platforms back a single token with more than
+	// one directory (e.g. FreeBSD's UUID token resolves under both
+	// /dev/gptid and /dev/ufsid), so try each in turn.
+	name := value
+	if token == labelToken || token == partlabelToken {
+		name = escapeLabel(value)
 	}
-	devicePath, err := canonicalizePath(searchPath)
-	if err != nil {
-		return nil, errors.Wrapf(ErrFollowLink, "no device with UUID %q", value)
+	var devicePath string
+	for _, directory := range directories {
+		searchPath := filepath.Join(directory, name)
+		if filepath.Base(searchPath) != name {
+			return nil, errors.Wrapf(ErrFollowLink, "value %q is not a valid %s", value, token)
+		}
+		if resolved, err := canonicalizePath(searchPath); err == nil {
+			devicePath = resolved
+			break
+		}
+	}
+	if devicePath == "" {
+		return nil, errors.Wrapf(ErrFollowLink, "no device with %s %q", token, value)
 	}
 
 	// Lookup mountpoints for device in global store
 	mountMutex.Lock()
 	defer mountMutex.Unlock()
-	if err := loadMountInfo(); err != nil {
+	cache, err := loadMountCache(selfMountSource)
+	if err != nil {
 		return nil, err
 	}
-	mnts, ok := mountsByDevice[devicePath]
+	cached, ok := cache.byDevice[devicePath]
 	if !ok {
 		return nil, errors.Wrapf(ErrFollowLink, "no mounts for device %q", devicePath)
 	}
+	// Copy before tagging with LinkToken so we don't mutate the Mounts
+	// that other callers may still be holding from the shared cache.
+	mnts := make([]*Mount, len(cached))
+	for i, mnt := range cached {
+		tagged := *mnt
+		tagged.LinkToken = token
+		mnts[i] = &tagged
+	}
 	return mnts, nil
 }
 
-// makeLink returns a link of the form <token>=<value> where value is the tag
-// value for the Mount's device. Currently, only "UUID" tokens are supported. An
-// error is returned if the mount has no device, or no UUID.
-func makeLink(mnt *Mount, token string) (string, error) {
-	if token != uuidToken {
-		return "", errors.Wrapf(ErrMakeLink, "token type %q not supported", token)
+// findTagForDevice searches directories in order for a symlink that resolves
+// to device, returning the (possibly escaped) symlink name if one is found.
+func findTagForDevice(directories []string, device string) (string, error) {
+	for _, directory := range directories {
+		dirContents, err := ioutil.ReadDir(directory)
+		if err != nil {
+			continue
+		}
+		for _, fileInfo := range dirContents {
+			if fileInfo.Mode()&os.ModeSymlink == 0 {
+				continue // Only interested in tag symlinks
+			}
+			name := fileInfo.Name()
+			devicePath, err := canonicalizePath(filepath.Join(directory, name))
+			if err != nil {
+				log.Print(err)
+				continue
+			}
+			if device == devicePath {
+				return name, nil
+			}
+		}
 	}
+	return "", errors.Errorf("no matching tag in %q", directories)
+}
+
+// makeLink returns a link of the form <token>=<value> where value is the tag
+// value for the Mount's device. tokens gives the preference order of link
+// types to try, trying each in turn until one resolves; if tokens is omitted,
+// mnt.LinkToken is tried first (so that a protector originally bound via, say,
+// LABEL round-trips instead of being silently re-linked via UUID), falling
+// back to defaultTokenPreference. An error is returned if the mount has no
+// device, or if none of the requested tokens resolve.
+func makeLink(mnt *Mount, tokens ...string) (string, error) {
 	if mnt.Device == "" {
 		return "", errors.Wrapf(ErrMakeLink, "no device for mount %q", mnt.Path)
 	}
-
-	dirContents, err := ioutil.ReadDir(uuidDirectory)
-	if err != nil {
-		return "", errors.Wrap(ErrMakeLink, err.Error())
+	if len(tokens) == 0 {
+		tokens = defaultTokenPreference
+		if mnt.LinkToken != "" {
+			tokens = append([]string{mnt.LinkToken}, tokens...)
+		}
 	}
-	for _, fileInfo := range dirContents {
-		if fileInfo.Mode()&os.ModeSymlink == 0 {
-			continue // Only interested in UUID symlinks
+
+	for _, token := range tokens {
+		directories, ok := tokenDirectories[token]
+		if !ok {
+			return "", errors.Wrapf(ErrMakeLink, "token type %q not supported", token)
 		}
-		uuid := fileInfo.Name()
-		devicePath, err := canonicalizePath(filepath.Join(uuidDirectory, uuid))
+		name, err := findTagForDevice(directories, mnt.Device)
 		if err != nil {
-			log.Print(err)
 			continue
 		}
-		if mnt.Device == devicePath {
-			return fmt.Sprintf("%s=%s", uuidToken, uuid), nil
+		value := name
+		if token == labelToken || token == partlabelToken {
+			value = unescapeLabel(name)
 		}
+		// mnt may be a pointer into the shared mountCache (e.g. the
+		// result of FindMount/GetMount), so hold mountMutex while
+		// writing through it, the same as loadMountCache's other
+		// writers.
+		mountMutex.Lock()
+		mnt.LinkToken = token
+		mountMutex.Unlock()
+		return fmt.Sprintf("%s=%s", token, value), nil
 	}
-	return "", errors.Wrapf(ErrMakeLink, "device %q has no UUID", mnt.Device)
+	return "", errors.Wrapf(ErrMakeLink, "device %q has no usable tag", mnt.Device)
 }
@@ -0,0 +1,35 @@
+//go:build freebsd
+// +build freebsd
+
+/*
+ * mountpoint_freebsd.go - FreeBSD-specific Statfs_t field access.
+ *
+ * Copyright 2017 Google Inc.
+ * Author: Joe Richey (joerichey@google.com)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package filesystem
+
+import "golang.org/x/sys/unix"
+
+// statfsToMount extracts the fields forEachStatfs needs from a FreeBSD
+// Statfs_t.
+func statfsToMount(stat *unix.Statfs_t) *Mount {
+	return &Mount{
+		Path:           cString(stat.Mntonname[:]),
+		Device:         cString(stat.Mntfromname[:]),
+		FilesystemType: cString(stat.Fstypename[:]),
+	}
+}
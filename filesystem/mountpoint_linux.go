@@ -0,0 +1,293 @@
+//go:build linux
+// +build linux
+
+/*
+ * mountpoint_linux.go - Linux implementation of mount scanning, by parsing
+ * /proc/<pid>/mountinfo.
+ *
+ * Copyright 2017 Google Inc.
+ * Author: Joe Richey (joerichey@google.com)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package filesystem
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// tokenDirectories gives the /dev/disk/by-* directories used to resolve each
+// supported link token to a device on Linux.
+var tokenDirectories = map[string][]string{
+	uuidToken:      {"/dev/disk/by-uuid"},
+	labelToken:     {"/dev/disk/by-label"},
+	partuuidToken:  {"/dev/disk/by-partuuid"},
+	partlabelToken: {"/dev/disk/by-partlabel"},
+}
+
+// mountInfoPath returns the path of the mountinfo file for source.
+func mountInfoPath(source string) string {
+	if source == selfMountSource {
+		return "/proc/self/mountinfo"
+	}
+	return "/proc/" + source[len("pid:"):] + "/mountinfo"
+}
+
+// Parse one line of /proc/<pid>/mountinfo.
+//
+// The line contains the following space-separated fields:
+//	[0] mount ID
+//	[1] parent ID
+//	[2] major:minor
+//	[3] root
+//	[4] mount point
+//	[5] mount options
+//	[6...n-1] optional field(s)
+//	[n] separator
+//	[n+1] filesystem type
+//	[n+2] mount source
+//	[n+3] super options
+//
+// For more details, see https://www.kernel.org/doc/Documentation/filesystems/proc.txt
+func parseMountInfoLine(line string) *Mount {
+	fields := strings.Split(line, " ")
+	if len(fields) < 10 {
+		return nil
+	}
+
+	// Count the optional fields.  In case new fields are appended later,
+	// don't simply assume that n == len(fields) - 4.
+	n := 6
+	for fields[n] != "-" {
+		n++
+		if n >= len(fields) {
+			return nil
+		}
+	}
+	if n+3 >= len(fields) {
+		return nil
+	}
+
+	mountID, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil
+	}
+	parentID, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil
+	}
+
+	var mnt *Mount = &Mount{
+		MountID:  mountID,
+		ParentID: parentID,
+		Root:     unescapeString(fields[3]),
+		Options:  strings.Split(fields[5], ","),
+	}
+	mnt.Propagation, mnt.PeerGroup, mnt.MasterID = parsePropagation(fields[6:n])
+	mnt.Path = unescapeString(fields[4])
+	mnt.FilesystemType = unescapeString(fields[n+1])
+	mnt.Device = unescapeString(fields[n+2])
+	mnt.SuperOptions = strings.Split(fields[n+3], ",")
+	return mnt
+}
+
+// parsePropagation interprets the optional fields of a mountinfo line
+// (everything between the mount options and the "-" separator) to determine
+// the mount's Propagation type, along with its peer group ID (for
+// PropagationShared) and master peer group ID (for PropagationSlave). A mount
+// can be both a slave and a member of its own peer group at once ("master:X
+// shared:Y"); Propagation collapses that to PropagationSlave, since that's
+// the propagation direction that matters for fscrypt (events flow in from
+// the master, regardless of whatever also flows to its peers), but peerGroup
+// is still populated so that information isn't lost.
+func parsePropagation(optionalFields []string) (prop Propagation, peerGroup, masterID int) {
+	prop = PropagationPrivate
+	for _, field := range optionalFields {
+		kv := strings.SplitN(field, ":", 2)
+		switch kv[0] {
+		case "shared":
+			if len(kv) == 2 {
+				peerGroup, _ = strconv.Atoi(kv[1])
+			}
+		case "master":
+			if len(kv) == 2 {
+				masterID, _ = strconv.Atoi(kv[1])
+			}
+		case "unbindable":
+			prop = PropagationUnbindable
+		}
+	}
+	switch {
+	case masterID != 0:
+		prop = PropagationSlave
+	case peerGroup != 0:
+		prop = PropagationShared
+	}
+	return
+}
+
+// scanMountInfo reads mountinfo lines from r, skipping any Mount that filter
+// excludes before paying the cost of canonicalizing and stat'ing it, and
+// calls fn for each Mount that remains. If filter requests that the scan stop
+// after a given Mount, scanning ends there without reading the rest of r. A
+// nil filter keeps every Mount. If fn returns an error, scanning stops and
+// that error is returned.
+func scanMountInfo(r io.Reader, filter FilterFunc, fn func(mnt *Mount) error) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		mnt := parseMountInfoLine(line)
+		if mnt == nil {
+			log.Printf("ignoring invalid mountinfo line %q", line)
+			continue
+		}
+
+		skip, stopAfter := false, false
+		if filter != nil {
+			skip, stopAfter = filter(mnt)
+		}
+		if !skip {
+			// Skip invalid mountpoints
+			var err error
+			if mnt.Path, err = canonicalizePath(mnt.Path); err != nil {
+				log.Printf("getting mnt_dir: %v", err)
+			} else if !isDir(mnt.Path) {
+				// We can only use mountpoints that are directories for fscrypt.
+				log.Printf("ignoring mountpoint %q because it is not a directory", mnt.Path)
+			} else {
+				// Only use real valid devices (unlike cgroups, tmpfs, ...)
+				if mnt.Device, err = canonicalizePath(mnt.Device); err != nil || !isDevice(mnt.Device) {
+					mnt.Device = ""
+				}
+				if err := fn(mnt); err != nil {
+					return err
+				}
+			}
+		}
+		if stopAfter {
+			break
+		}
+	}
+	return scanner.Err()
+}
+
+// scanMountsForSource parses the mountinfo file for source into a new
+// mountCache. The caller must hold mountMutex.
+func scanMountsForSource(source string) (*mountCache, error) {
+	file, err := os.Open(mountInfoPath(source))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cache := newMountCache()
+	if err := scanMountInfo(file, nil, func(mnt *Mount) error {
+		cache.addMount(mnt)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// forEachMountForSource scans the mountinfo file for source, calling fn for
+// every Mount that filter does not skip. It always rescans and never touches
+// the mountCaches.
+func forEachMountForSource(source string, filter FilterFunc, fn func(mnt *Mount) error) error {
+	file, err := os.Open(mountInfoPath(source))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return scanMountInfo(file, filter, fn)
+}
+
+// AllFilesystemsFromReader is like AllFilesystems, but parses mountinfo data
+// read from r instead of opening a /proc/<pid>/mountinfo file. This lets
+// fscrypt be driven against mountinfo data gathered out-of-band, e.g. by a
+// privileged helper that can read another mount namespace's mountinfo but
+// cannot itself join that namespace. Because r can only be read once, the
+// result isn't added to the caches used by AllFilesystems or
+// AllFilesystemsForPID.
+func AllFilesystemsFromReader(r io.Reader) ([]*Mount, error) {
+	cache := newMountCache()
+	if err := scanMountInfo(r, nil, func(mnt *Mount) error {
+		cache.addMount(mnt)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return sortedMounts(cache), nil
+}
+
+// isMountpointFast implements the openat2(2) RESOLVE_NO_XDEV fast path for
+// IsMountpoint: open path's parent with O_PATH, then openat2 the basename
+// with RESOLVE_NO_XDEV, which fails with EXDEV if the basename is a
+// mountpoint. This avoids scanning mountinfo for the common case. handled is
+// false if the fast path couldn't be used (pre-5.6 kernel, or any other
+// error opening the paths), in which case the caller should fall back to
+// isMountpointSlow.
+func isMountpointFast(path string) (mounted, handled bool, err error) {
+	path, err = canonicalizePath(path)
+	if err != nil {
+		return false, true, err
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		// The root directory is always a mountpoint.
+		return true, true, nil
+	}
+	base := filepath.Base(path)
+
+	parentFD, err := unix.Open(parent, unix.O_PATH|unix.O_DIRECTORY, 0)
+	if err != nil {
+		return false, false, nil
+	}
+	defer unix.Close(parentFD)
+
+	how := unix.OpenHow{Flags: unix.O_PATH, Resolve: unix.RESOLVE_NO_XDEV}
+	childFD, err := unix.Openat2(parentFD, base, &how)
+	if err != nil {
+		if err == unix.EXDEV {
+			// base is on a different device/mount than parent.
+			return true, true, nil
+		}
+		if err == unix.ENOSYS {
+			// openat2 isn't available (kernel < 5.6).
+			return false, false, nil
+		}
+		return false, false, nil
+	}
+	defer unix.Close(childFD)
+
+	var parentStat, childStat unix.Stat_t
+	if err := unix.Fstat(parentFD, &parentStat); err != nil {
+		return false, false, nil
+	}
+	if err := unix.Fstat(childFD, &childStat); err != nil {
+		return false, false, nil
+	}
+	// base is a mountpoint if it's on a different device than its parent,
+	// or (for the root of a mount namespace) if it is its own parent.
+	mounted = parentStat.Dev != childStat.Dev || parentStat.Ino == childStat.Ino
+	return mounted, true, nil
+}
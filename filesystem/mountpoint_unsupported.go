@@ -0,0 +1,53 @@
+//go:build !linux && !freebsd && !openbsd
+// +build !linux,!freebsd,!openbsd
+
+/*
+ * mountpoint_unsupported.go - Stub mount scanning for platforms fscrypt
+ * doesn't otherwise support, so that the rest of the package still compiles.
+ *
+ * Copyright 2017 Google Inc.
+ * Author: Joe Richey (joerichey@google.com)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License"); you may not
+ * use this file except in compliance with the License. You may obtain a copy of
+ * the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+ * WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+ * License for the specific language governing permissions and limitations under
+ * the License.
+ */
+
+package filesystem
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// tokenDirectories is empty on unsupported platforms; no link token can be
+// resolved to a device.
+var tokenDirectories = map[string][]string{}
+
+func scanMountsForSource(source string) (*mountCache, error) {
+	return nil, ErrNotImplemented
+}
+
+func forEachMountForSource(source string, filter FilterFunc, fn func(mnt *Mount) error) error {
+	return ErrNotImplemented
+}
+
+// AllFilesystemsFromReader is unsupported on this platform.
+func AllFilesystemsFromReader(r io.Reader) ([]*Mount, error) {
+	return nil, errors.Wrap(ErrNotImplemented, "AllFilesystemsFromReader on this platform")
+}
+
+// isMountpointFast is unsupported on this platform; IsMountpoint always falls
+// back to isMountpointSlow.
+func isMountpointFast(path string) (mounted, handled bool, err error) {
+	return false, false, nil
+}